@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// BundleResult is the outcome of a single bundle() call: the bundled
+// function code, its source map (only with --bundle-sourcemap=external; an
+// inline map is embedded in Contents instead), and its size, so callers
+// like watch-mode can report size deltas between rebuilds.
+type BundleResult struct {
+	Contents  []byte
+	SourceMap []byte
+	SizeBytes int
+}
+
+// lastBundleSizeBytes remembers the previous bundle's size so
+// deployFunction can report how much it changed on the next rebuild,
+// e.g. while watching.
+var lastBundleSizeBytes int
+
+func bundlePlatformFromFlag(p string) (api.Platform, error) {
+	switch p {
+	case "node":
+		return api.PlatformNode, nil
+	case "browser":
+		return api.PlatformBrowser, nil
+	case "neutral":
+		return api.PlatformNeutral, nil
+	default:
+		return api.PlatformDefault, fmt.Errorf("bundle platform (%s) not supported", p)
+	}
+}
+
+func bundleTargetFromFlag(t string) (api.Target, error) {
+	switch t {
+	case "":
+		return api.DefaultTarget, nil
+	case "es2015":
+		return api.ES2015, nil
+	case "es2016":
+		return api.ES2016, nil
+	case "es2017":
+		return api.ES2017, nil
+	case "es2018":
+		return api.ES2018, nil
+	case "es2019":
+		return api.ES2019, nil
+	case "es2020":
+		return api.ES2020, nil
+	case "es2021":
+		return api.ES2021, nil
+	case "es2022":
+		return api.ES2022, nil
+	case "esnext":
+		return api.ESNext, nil
+	default:
+		return api.DefaultTarget, fmt.Errorf("bundle target (%s) not supported", t)
+	}
+}
+
+func bundleSourcemapFromFlag(s string) (api.SourceMap, error) {
+	switch s {
+	case "", "none":
+		return api.SourceMapNone, nil
+	case "inline":
+		return api.SourceMapInline, nil
+	case "external":
+		return api.SourceMapExternal, nil
+	default:
+		return api.SourceMapNone, fmt.Errorf("bundle sourcemap mode (%s) not supported", s)
+	}
+}
+
+// parseBundleDefines turns repeated --bundle-define KEY=VALUE flags into
+// the map esbuild's Define option expects.
+func parseBundleDefines(values []string) (map[string]string, error) {
+	defines := make(map[string]string, len(values))
+	for _, v := range values {
+		pair := strings.SplitN(v, "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("invalid --bundle-define %q, expected KEY=VALUE", v)
+		}
+		defines[pair[0]] = pair[1]
+	}
+	return defines, nil
+}
+
+// bundle runs esbuild over the configured entry points using the
+// --bundle-* flags, logs any esbuild warnings, and returns the bundled
+// output (and the separate source map, if an external one was requested).
+func bundle() (*BundleResult, error) {
+	platform, err := bundlePlatformFromFlag(bundlePlatform)
+	if err != nil {
+		return nil, err
+	}
+	target, err := bundleTargetFromFlag(bundleTarget)
+	if err != nil {
+		return nil, err
+	}
+	sourcemap, err := bundleSourcemapFromFlag(bundleSourcemap)
+	if err != nil {
+		return nil, err
+	}
+	defines, err := parseBundleDefines(bundleDefine)
+	if err != nil {
+		return nil, err
+	}
+
+	entryPoints := bundleEntries
+	if len(entryPoints) == 0 {
+		entryPoints = []string{path.Join(funcDir, "index.js")}
+	}
+
+	// esbuild only emits an external source map when it has an output path;
+	// Write is left false, so nothing is written there.
+	result := api.Build(api.BuildOptions{
+		Bundle:            true,
+		EntryPoints:       entryPoints,
+		Outfile:           path.Join(os.TempDir(), "atroctl", "index.js"),
+		Platform:          platform,
+		Target:            target,
+		MinifyWhitespace:  bundleMinify,
+		MinifyIdentifiers: bundleMinify,
+		MinifySyntax:      bundleMinify,
+		Sourcemap:         sourcemap,
+		External:          bundleExternal,
+		Define:            defines,
+		LogLevel:          api.LogLevelSilent,
+	})
+
+	for _, w := range result.Warnings {
+		defaultLogger.Warn("bundle", w.Text)
+	}
+	if len(result.Errors) > 0 {
+		for _, e := range result.Errors {
+			defaultLogger.Error("bundle", e.Text)
+		}
+		return nil, errors.New("error while bundling")
+	}
+
+	br := &BundleResult{}
+	var outputs int
+	for _, f := range result.OutputFiles {
+		if strings.HasSuffix(f.Path, ".map") {
+			br.SourceMap = f.Contents
+			continue
+		}
+		outputs++
+		br.Contents = f.Contents
+	}
+	if outputs > 1 {
+		return nil, fmt.Errorf("bundle produced %d output files from %d --bundle-entry entries, but atroctl can only deploy a single function bundle", outputs, len(entryPoints))
+	}
+	br.SizeBytes = len(br.Contents)
+	return br, nil
+}
+
+func deployFunction(ctx context.Context, deployId string) error {
+	defaultLogger.Info("functions", fmt.Sprintf("starting to deploy functions in '%s'", funcDir), deployIdField(deployId))
+	result, err := bundle()
+	if err != nil {
+		return err
+	}
+	if lastBundleSizeBytes > 0 {
+		defaultLogger.Debug("functions", fmt.Sprintf("created bundle (%d bytes, %+d since last build)", result.SizeBytes, result.SizeBytes-lastBundleSizeBytes), deployIdField(deployId))
+	} else {
+		defaultLogger.Debug("functions", fmt.Sprintf("created bundle (%d bytes)", result.SizeBytes), deployIdField(deployId))
+	}
+	lastBundleSizeBytes = result.SizeBytes
+
+	start := time.Now()
+	resp, err := httpPut(ctx, fmt.Sprintf("%s/deploy/%s/function", url, deployId), "text/plain", bytes.NewReader(result.Contents))
+	elapsed := time.Since(start)
+	if err != nil {
+		return fmt.Errorf("error deploying bundle: %w", err)
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		defaultLogger.Info("functions", "deployed bundle", deployIdField(deployId), elapsedField(elapsed), statusCodeField(resp.StatusCode))
+	} else {
+		defaultLogger.Error("functions", "failed to deploy bundle", deployIdField(deployId), elapsedField(elapsed), statusCodeField(resp.StatusCode))
+		return fmt.Errorf("failed to deploy bundle")
+	}
+
+	if bundleSourcemap == "external" && len(result.SourceMap) > 0 {
+		if err := deployFunctionSourceMap(ctx, deployId, result.SourceMap); err != nil {
+			return err
+		}
+	}
+
+	defaultLogger.Info("functions", "successfully deployed", deployIdField(deployId))
+	return nil
+}
+
+func deployFunctionSourceMap(ctx context.Context, deployId string, sourceMap []byte) error {
+	start := time.Now()
+	resp, err := httpPut(ctx, fmt.Sprintf("%s/deploy/%s/function.map", url, deployId), "application/json", bytes.NewReader(sourceMap))
+	elapsed := time.Since(start)
+	if err != nil {
+		return fmt.Errorf("error deploying source map: %w", err)
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		defaultLogger.Info("functions", "deployed source map", deployIdField(deployId), elapsedField(elapsed), statusCodeField(resp.StatusCode))
+		return nil
+	}
+	defaultLogger.Error("functions", "failed to deploy source map", deployIdField(deployId), elapsedField(elapsed), statusCodeField(resp.StatusCode))
+	return fmt.Errorf("failed to deploy source map")
+}
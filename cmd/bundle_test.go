@@ -0,0 +1,50 @@
+package cmd
+
+import "testing"
+
+func TestBundlePlatformFromFlag(t *testing.T) {
+	if _, err := bundlePlatformFromFlag("bogus"); err == nil {
+		t.Fatalf("expected an error for an unsupported platform")
+	}
+	for _, p := range []string{"node", "browser", "neutral"} {
+		if _, err := bundlePlatformFromFlag(p); err != nil {
+			t.Fatalf("unexpected error for platform %q: %s", p, err)
+		}
+	}
+}
+
+func TestBundleTargetFromFlag(t *testing.T) {
+	if _, err := bundleTargetFromFlag("bogus"); err == nil {
+		t.Fatalf("expected an error for an unsupported target")
+	}
+	for _, target := range []string{"", "es2020", "esnext"} {
+		if _, err := bundleTargetFromFlag(target); err != nil {
+			t.Fatalf("unexpected error for target %q: %s", target, err)
+		}
+	}
+}
+
+func TestBundleSourcemapFromFlag(t *testing.T) {
+	if _, err := bundleSourcemapFromFlag("bogus"); err == nil {
+		t.Fatalf("expected an error for an unsupported sourcemap mode")
+	}
+	for _, mode := range []string{"", "none", "inline", "external"} {
+		if _, err := bundleSourcemapFromFlag(mode); err != nil {
+			t.Fatalf("unexpected error for sourcemap mode %q: %s", mode, err)
+		}
+	}
+}
+
+func TestParseBundleDefines(t *testing.T) {
+	defines, err := parseBundleDefines([]string{"VERSION=1.0.0", "DEBUG=false"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if defines["VERSION"] != "1.0.0" || defines["DEBUG"] != "false" {
+		t.Fatalf("unexpected defines: %+v", defines)
+	}
+
+	if _, err := parseBundleDefines([]string{"NOVALUE"}); err == nil {
+		t.Fatalf("expected an error for a define missing '='")
+	}
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	configFile string
+	profile    string
+
+	// profileSecrets holds the active profile's "secrets" map, merged into
+	// envvars() alongside ATROCITY_* environment variables.
+	profileSecrets map[string]string
+)
+
+const (
+	atroctlConfigFile = "ATROCTL_CONFIG"
+	atroctlProfile    = "ATROCTL_PROFILE"
+)
+
+// profileConfig is a single named environment's settings loaded from
+// atroctl.yaml / atroctl.toml, e.g. "dev", "staging", or "prod".
+type profileConfig struct {
+	Url          string            `mapstructure:"url"`
+	ApiKey       string            `mapstructure:"api_key"`
+	ApiSecretKey string            `mapstructure:"api_secret_key"`
+	FuncDir      string            `mapstructure:"func_dir"`
+	StaticDir    string            `mapstructure:"static_dir"`
+	Strategy     string            `mapstructure:"strategy"`
+	Secrets      map[string]string `mapstructure:"secrets"`
+}
+
+// loadProfiles reads atroctl's config file - ./atroctl.yaml,
+// $XDG_CONFIG_HOME/atroctl/config.yaml, or --config - and returns its
+// named profiles. A missing file is not an error; it just means no
+// profile overrides exist. ./atroctl.yaml takes priority over the XDG
+// path when both exist, since viper can only search one config name per
+// path and the two conventions use different names.
+func loadProfiles() (map[string]profileConfig, error) {
+	v := viper.New()
+	if configFile != "" {
+		v.SetConfigFile(configFile)
+	} else {
+		v.SetConfigName("atroctl")
+		v.AddConfigPath(".")
+	}
+
+	err := v.ReadInConfig()
+	if configFile == "" && isConfigFileNotFound(err) {
+		if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+			v = viper.New()
+			v.SetConfigName("config")
+			v.AddConfigPath(filepath.Join(xdg, "atroctl"))
+			err = v.ReadInConfig()
+		}
+	}
+	if err != nil {
+		if isConfigFileNotFound(err) {
+			return map[string]profileConfig{}, nil
+		}
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var profiles map[string]profileConfig
+	if err := v.Unmarshal(&profiles); err != nil {
+		return nil, fmt.Errorf("error parsing config file: %w", err)
+	}
+
+	// viper lower-cases every key it reads, including nested map keys, so
+	// profiles[x].Secrets would otherwise come back with the user's secret
+	// names mangled (e.g. PG_CONNECTION -> pg_connection). Re-read the raw
+	// file to recover the "secrets" sub-map with its original casing, since
+	// that's the exact name envvars() deploys it under.
+	secrets, err := loadRawSecrets(v.ConfigFileUsed())
+	if err != nil {
+		return nil, err
+	}
+	for name, profile := range profiles {
+		profile.Secrets = secrets[name]
+		profiles[name] = profile
+	}
+	return profiles, nil
+}
+
+func isConfigFileNotFound(err error) bool {
+	var notFound viper.ConfigFileNotFoundError
+	return errors.As(err, &notFound) || os.IsNotExist(err)
+}
+
+// loadRawSecrets re-parses path outside of viper to recover each profile's
+// "secrets" map with its keys' original casing intact.
+func loadRawSecrets(path string) (map[string]map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var raw map[string]struct {
+		Secrets map[string]string `yaml:"secrets" toml:"secrets"`
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		err = yaml.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing config file: %w", err)
+	}
+
+	secrets := make(map[string]map[string]string, len(raw))
+	for name, p := range raw {
+		secrets[name] = p.Secrets
+	}
+	return secrets, nil
+}
+
+// resolveConfig loads the config file and resolves every setting using
+// atroctl's precedence: flag > env > profile > default. It runs as
+// rootCmd's PersistentPreRunE so flags are already parsed by the time it
+// executes, unlike the old init()-time resolution it replaces.
+func resolveConfig(cmd *cobra.Command, args []string) error {
+	profile = resolveValue(profile, atroctlProfile, "", "")
+
+	profiles, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+	active := profiles[profile]
+
+	url = resolveValue(url, atroctlUrl, active.Url, "http://localhost:9090")
+	apiKey = resolveValue(apiKey, atroctlApiKey, active.ApiKey, "")
+	apiSecretKey = resolveValue(apiSecretKey, atroctlApiSecretKey, active.ApiSecretKey, "")
+
+	funcDir = resolveValue(funcDir, atroctlFuncDir, active.FuncDir, "src")
+	staticDir = resolveValue(staticDir, atroctlStaticDir, active.StaticDir, "")
+	strategy = resolveValue(strategy, atroctlStrategy, active.Strategy, "bluegreen")
+
+	profileSecrets = active.Secrets
+
+	logFormat = resolveValue(logFormat, atroctlLogFormat, "", "text")
+	defaultLogger.format = logFormat
+	logLevelFlag = resolveValue(logLevelFlag, atroctlLogLevel, "", "info")
+	if lvl, err := parseLogLevel(logLevelFlag); err == nil {
+		defaultLogger.level = lvl
+	}
+
+	return nil
+}
+
+// resolveValue applies atroctl's flag > env > profile > default
+// precedence for a single setting.
+func resolveValue(flagValue, envVar, profileValue, fallback string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if v := os.Getenv(envVar); v != "" {
+		return v
+	}
+	if profileValue != "" {
+		return profileValue
+	}
+	return fallback
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", fmt.Sprintf("path to the atroctl config file [%s]", atroctlConfigFile))
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", fmt.Sprintf("the named profile to use from the config file [%s]", atroctlProfile))
+	rootCmd.PersistentPreRunE = resolveConfig
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveValuePrecedence(t *testing.T) {
+	cases := []struct {
+		name                                        string
+		flagValue, envValue, profileValue, fallback string
+		want                                        string
+	}{
+		{name: "flag wins", flagValue: "flag", envValue: "env", profileValue: "profile", fallback: "fallback", want: "flag"},
+		{name: "env wins over profile", flagValue: "", envValue: "env", profileValue: "profile", fallback: "fallback", want: "env"},
+		{name: "profile wins over default", flagValue: "", envValue: "", profileValue: "profile", fallback: "fallback", want: "profile"},
+		{name: "falls back to default", flagValue: "", envValue: "", profileValue: "", fallback: "fallback", want: "fallback"},
+	}
+
+	const envVar = "ATROCTL_TEST_RESOLVE_VALUE"
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if c.envValue != "" {
+				t.Setenv(envVar, c.envValue)
+			} else {
+				t.Setenv(envVar, "")
+			}
+			got := resolveValue(c.flagValue, envVar, c.profileValue, c.fallback)
+			if got != c.want {
+				t.Fatalf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestLoadProfilesFromYaml(t *testing.T) {
+	dir := t.TempDir()
+	content := `
+dev:
+  url: http://localhost:9090
+  strategy: uuid
+  secrets:
+    PG_CONNECTION: postgres://dev
+prod:
+  url: https://atrocity.example.com
+  api_key: prod-key
+  func_dir: server
+`
+	path := filepath.Join(dir, "atroctl.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("writing config file: %s", err)
+	}
+
+	origConfigFile := configFile
+	configFile = path
+	defer func() { configFile = origConfigFile }()
+
+	profiles, err := loadProfiles()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if profiles["dev"].Strategy != "uuid" || profiles["dev"].Secrets["PG_CONNECTION"] != "postgres://dev" {
+		t.Fatalf("unexpected dev profile: %+v", profiles["dev"])
+	}
+	if profiles["prod"].Url != "https://atrocity.example.com" || profiles["prod"].FuncDir != "server" {
+		t.Fatalf("unexpected prod profile: %+v", profiles["prod"])
+	}
+}
+
+// TestLoadProfilesPreservesSecretKeyCasing pins a regression: viper
+// lower-cases every key it unmarshals, including nested maps, so a naive
+// Unmarshal into profileConfig.Secrets would rename PG_CONNECTION to
+// pg_connection. Since envvars() deploys secrets under the exact name
+// written in the config file, that silently breaks every mixed-case or
+// upper-case secret name.
+func TestLoadProfilesPreservesSecretKeyCasing(t *testing.T) {
+	dir := t.TempDir()
+	content := `
+dev:
+  secrets:
+    PG_CONNECTION: postgres://dev
+    Mixed_Case: value
+`
+	path := filepath.Join(dir, "atroctl.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("writing config file: %s", err)
+	}
+
+	origConfigFile := configFile
+	configFile = path
+	defer func() { configFile = origConfigFile }()
+
+	profiles, err := loadProfiles()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	secrets := profiles["dev"].Secrets
+	if secrets["PG_CONNECTION"] != "postgres://dev" || secrets["Mixed_Case"] != "value" {
+		t.Fatalf("secret keys did not survive with their original casing: %+v", secrets)
+	}
+}
+
+func TestLoadProfilesMissingFile(t *testing.T) {
+	origConfigFile := configFile
+	configFile = filepath.Join(t.TempDir(), "does-not-exist.yaml")
+	defer func() { configFile = origConfigFile }()
+
+	profiles, err := loadProfiles()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(profiles) != 0 {
+		t.Fatalf("expected no profiles, got %+v", profiles)
+	}
+}
@@ -2,6 +2,10 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -9,28 +13,48 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
-	"path"
+	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/spf13/cobra"
-
-	"github.com/evanw/esbuild/pkg/api"
 )
 
 var (
-	funcDir   string
-	staticDir string
-	strategy  string
-	watch     bool
+	funcDir       string
+	staticDir     string
+	strategy      string
+	watch         bool
+	watchDebounce time.Duration
+	forceFull     bool
+	concurrency   int
+
+	bundlePlatform  string
+	bundleTarget    string
+	bundleMinify    bool
+	bundleSourcemap string
+	bundleEntries   []string
+	bundleExternal  []string
+	bundleDefine    []string
 )
 
 const (
 	atroctlFuncDir   = "ATROCTL_FUNC_DIR"
 	atroctlStaticDir = "ATROCTL_STATIC_DIR"
 	atroctlStrategy  = "ATROCTL_STRATEGY"
+
+	defaultWatchDebounce = 300 * time.Millisecond
+
+	atroctlIgnoreFile = ".atroctlignore"
+
+	defaultConcurrency = 8
 )
 
 var deployCmd = &cobra.Command{
@@ -41,14 +65,21 @@ var deployCmd = &cobra.Command{
 Strategies:
 When deploying you'll need to choose a strategy.
 * bluegreen = rotates between blue and green deployments
-* gitrev    = (TBD) uses 'git rev-parse HEAD' as the deployment id
-* uuid      = (TBD) uses a random uuid as the deployment id
+* gitrev    = uses the current git revision (falling back to 'git describe' outside a repo) as the deployment id
+* uuid      = uses a random uuid as the deployment id
 
 Secrets:
 Any environment variable that starts with ATROCITY_ will be deployed to Atrocity.
 The secret will be available to atrocity functions without the ATROCITY_. For example,
 ATROCITY_PG_CONNECTION will be available as PG_CONNECTION.
 
+Config:
+Settings can also come from an atroctl.yaml or atroctl.toml config file (./atroctl.yaml,
+$XDG_CONFIG_HOME/atroctl/config.yaml, or --config), with a "secrets" map merged in
+alongside ATROCITY_ environment variables. Each top-level key is a named profile
+(e.g. dev, staging, prod), selected with --profile or ATROCTL_PROFILE. Settings
+are resolved as: flag > env var > profile > default.
+
 Examples:
   # deploys all *.js files recursively in the "src" directory to http://localhost:9090 using the bluegreen strategy
   atroctl deploy
@@ -60,19 +91,21 @@ Examples:
 	RunE: func(cmd *cobra.Command, args []string) error {
 		printHeader(cmd.Parent().Version)
 
-		strategyFunc, err := resolveStrategy()
+		strat, err := resolveStrategy()
 		if err != nil {
 			return err
 		}
+		deployFn := deployWith(strat)
 
-		err = strategyFunc()
+		ctx := cmd.Context()
+		err = deployFn(ctx)
 		if err != nil {
-			p("error", "%s\n", err)
+			defaultLogger.Error("deploy", err.Error(), errField(err))
 			return err
 		}
 
 		if watch {
-			err = startWatching(strategyFunc)
+			err = startWatching(ctx, deployFn)
 			if err != nil {
 				return err
 			}
@@ -82,91 +115,304 @@ Examples:
 	},
 }
 
-type strategyFunction func() error
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "show deployment history",
+	Long:  `Show the deployments recorded by Atrocity, most recent first.`,
+	Args:  cobra.MaximumNArgs(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		records, err := getDeployHistory(cmd.Context())
+		if err != nil {
+			return err
+		}
+		printDeployHistory(records)
+		return nil
+	},
+}
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback [deployId]",
+	Short: "reactivate a prior deployment",
+	Long: `Rollback reactivates a previous deployment id without running the
+rest of the deploy pipeline, e.g. to recover from a bad release.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		deployId := args[0]
+		ctx := cmd.Context()
+		records, err := getDeployHistory(ctx)
+		if err != nil {
+			return err
+		}
+		if !deployHistoryContains(records, deployId) {
+			return fmt.Errorf("deployment %s not found in history", deployId)
+		}
+		return activateDeployment(ctx, deployId)
+	},
+}
+
+// deployFunc performs a single deployment, e.g. one triggered by the
+// initial run or by a file system change while watching. It aborts early
+// if ctx is cancelled.
+type deployFunc func(ctx context.Context) error
+
+// Strategy decides which deployment id the next deployment should use.
+type Strategy interface {
+	NextDeployId(ctx context.Context) (string, error)
+}
+
+// deployWith adapts a Strategy into a deployFunc that resolves the next
+// deploy id and runs the full deploy pipeline against it.
+func deployWith(strat Strategy) deployFunc {
+	return func(ctx context.Context) error {
+		deployId, err := strat.NextDeployId(ctx)
+		if err != nil {
+			return err
+		}
+		return deploy(ctx, deployId)
+	}
+}
 
-func resolveStrategy() (strategyFunction, error) {
+func resolveStrategy() (Strategy, error) {
 	switch strategy {
 	case "bluegreen":
-		return bluegreen, nil
+		return bluegreenStrategy{}, nil
+	case "gitrev":
+		return gitrevStrategy{}, nil
+	case "uuid":
+		return uuidStrategy{}, nil
 	default:
 		return nil, fmt.Errorf("strategy (%s) not supported", strategy)
 	}
 }
 
+// bluegreenStrategy rotates between the "blue" and "green" deployment ids
+// based on whichever one is currently active.
+type bluegreenStrategy struct{}
+
+func (bluegreenStrategy) NextDeployId(ctx context.Context) (string, error) {
+	deployId, err := getDeployId(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error getting deploy id: %w", err)
+	}
+	if deployId == "blue" {
+		return "green", nil
+	} else if deployId == "green" {
+		return "blue", nil
+	}
+	return "", fmt.Errorf("failed to get current deploy id")
+}
+
+// gitrevStrategy uses the short SHA of the current git revision in funcDir
+// as the deployment id, falling back to 'git describe' when funcDir isn't
+// inside a git repository (e.g. a fresh checkout with no history yet).
+type gitrevStrategy struct{}
+
+func (gitrevStrategy) NextDeployId(ctx context.Context) (string, error) {
+	if rev, err := gitCommand(ctx, funcDir, "rev-parse", "--short", "HEAD"); err == nil {
+		return rev, nil
+	}
+	rev, err := gitCommand(ctx, funcDir, "describe", "--tags", "--always", "--dirty")
+	if err != nil {
+		return "", fmt.Errorf("error resolving git revision: %w", err)
+	}
+	return rev, nil
+}
+
+// gitCommand runs git with the given args in dir and returns its trimmed
+// stdout.
+func gitCommand(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// uuidStrategy uses a random v4 uuid as the deployment id.
+type uuidStrategy struct{}
+
+func (uuidStrategy) NextDeployId(ctx context.Context) (string, error) {
+	return uuid.NewString(), nil
+}
+
 func printHeader(version string) {
-	p("atroctl", "version %s\n", version)
-	p("atroctl", "starting deployment to %s\n", url)
-	p("strategy", "using strategy %s\n", strategy)
+	defaultLogger.Info("atroctl", fmt.Sprintf("version %s", version))
+	defaultLogger.Info("atroctl", fmt.Sprintf("starting deployment to %s", url))
+	defaultLogger.Info("strategy", fmt.Sprintf("using strategy %s", strategy))
 }
 
-func p(key, msg string, args ...interface{}) {
-	if key == "" {
-		fmt.Printf(msg, args...)
-		return
+// loadIgnorePatterns reads the gitignore-style globs from dir's
+// .atroctlignore file, if any. A missing file is not an error.
+func loadIgnorePatterns(dir string) ([]string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, atroctlIgnoreFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
-	fmt.Printf("%10s: %s", strings.ToUpper(key), fmt.Sprintf(msg, args...))
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, nil
 }
 
-func startWatching(fn strategyFunction) error {
-	p("watch", "starting to watch directories for changes\n")
+// watchRootFor returns whichever of funcDir/staticDir p is under, so
+// shouldIgnore can compute p's path relative to the right watched root.
+func watchRootFor(p string) string {
+	if staticDir != "" && strings.HasPrefix(p, staticDir) {
+		return staticDir
+	}
+	return funcDir
+}
+
+// shouldIgnore reports whether p, a path under the watched root, should be
+// skipped while watching: any dotfile, any "node_modules" directory, or
+// anything matched by patterns. Only path components relative to root are
+// considered, so an unrelated dotfile higher up in p's absolute path (e.g.
+// root itself living under a "~/.config" directory) doesn't ignore root.
+func shouldIgnore(root, p string, patterns []string) bool {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		rel = filepath.Base(p)
+	}
+	if rel == "." {
+		return false
+	}
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if part == "node_modules" {
+			return true
+		}
+		if strings.HasPrefix(part, ".") && part != "." && part != ".." {
+			return true
+		}
+	}
+	base := filepath.Base(p)
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.ToSlash(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// startWatching watches funcDir (and staticDir, if set) for changes and
+// redeploys via fn, debouncing bursts of file system events into a single
+// redeploy and cancelling any in-flight deploy when a new change arrives.
+func startWatching(ctx context.Context, fn deployFunc) error {
+	defaultLogger.Info("watch", "starting to watch directories for changes")
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return err
 	}
 	defer func() { _ = watcher.Close() }()
 
-	done := make(chan bool)
-	go func() {
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				if event.Op&fsnotify.Write == fsnotify.Write {
-					fmt.Printf("\n\n")
-					p("watch", "detected file system change\n")
-					err = fn()
-					if err != nil {
-						p("error", "%s\n", err)
-					}
-					fmt.Print("\n\n\a")
-				}
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
+	ignorePatterns, err := loadIgnorePatterns(funcDir)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", atroctlIgnoreFile, err)
+	}
+
+	addTree := func(dir string) error {
+		return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if shouldIgnore(dir, p, ignorePatterns) {
+				if d.IsDir() {
+					return fs.SkipDir
 				}
-				p("error", "%s\n", err)
+				return nil
 			}
-		}
-	}()
-
-	err = filepath.WalkDir(funcDir, func(path string, d fs.DirEntry, err error) error {
-		if d.IsDir() {
-			p("watch", path+"\n")
-			return watcher.Add(path)
-		}
-		return nil
-	})
-	if staticDir != "" {
-		err = filepath.WalkDir(staticDir, func(path string, d fs.DirEntry, err error) error {
 			if d.IsDir() {
-				p("watch", path+"\n")
-				return watcher.Add(path)
+				defaultLogger.Debug("watch", p)
+				return watcher.Add(p)
 			}
 			return nil
 		})
 	}
-	if err != nil {
+
+	if err := addTree(funcDir); err != nil {
 		return err
 	}
-	<-done
+	if staticDir != "" {
+		if err := addTree(staticDir); err != nil {
+			return err
+		}
+	}
 
-	return nil
+	var (
+		mu         sync.Mutex
+		timer      *time.Timer
+		cancelPrev context.CancelFunc
+	)
+
+	triggerDeploy := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if cancelPrev != nil {
+			cancelPrev()
+		}
+		deployCtx, cancel := context.WithCancel(ctx)
+		cancelPrev = cancel
+		go func() {
+			defaultLogger.Info("watch", "detected file system change")
+			if err := fn(deployCtx); err != nil && !errors.Is(err, context.Canceled) {
+				defaultLogger.Error("watch", "deploy failed", errField(err))
+			}
+		}()
+	}
+
+	scheduleDeploy := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = time.AfterFunc(watchDebounce, triggerDeploy)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if shouldIgnore(watchRootFor(ev.Name), ev.Name, ignorePatterns) {
+				continue
+			}
+			if ev.Op&fsnotify.Create == fsnotify.Create {
+				if info, statErr := os.Stat(ev.Name); statErr == nil && info.IsDir() {
+					if err := addTree(ev.Name); err != nil {
+						defaultLogger.Error("watch", "error watching new directory", errField(err))
+					}
+				}
+			}
+			if ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 {
+				scheduleDeploy()
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			defaultLogger.Error("watch", err.Error(), errField(err))
+		}
+	}
 }
 
-func httpCall(method, url, contentType string, body io.Reader) (*http.Response, error) {
-	req, err := http.NewRequest(method, url, body)
+func httpCall(ctx context.Context, method, url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -181,60 +427,49 @@ func httpCall(method, url, contentType string, body io.Reader) (*http.Response,
 	return client.Do(req)
 }
 
-func httpPut(url, contentType string, body io.Reader) (*http.Response, error) {
-	return httpCall(http.MethodPut, url, contentType, body)
+func httpPut(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
+	return httpCall(ctx, http.MethodPut, url, contentType, body)
 }
 
-func httpPost(url, contentType string, body io.Reader) (*http.Response, error) {
-	return httpCall(http.MethodPost, url, contentType, body)
+func httpPost(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
+	return httpCall(ctx, http.MethodPost, url, contentType, body)
 }
 
-func httpGet(url string) (*http.Response, error) {
-	return httpCall(http.MethodGet, url, "text/plain", nil)
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	return httpCall(ctx, http.MethodGet, url, "text/plain", nil)
 }
 
-func bluegreen() error {
-	deployId, err := getDeployId()
-	if err != nil {
-		return fmt.Errorf("error getting deploy id: %w", err)
-	}
-	if deployId == "blue" {
-		deployId = "green"
-	} else if deployId == "green" {
-		deployId = "blue"
-	} else {
-		return fmt.Errorf("failed to get current deploy id")
-	}
-	return deploy(deployId)
+func httpDelete(ctx context.Context, url string) (*http.Response, error) {
+	return httpCall(ctx, http.MethodDelete, url, "text/plain", nil)
 }
 
-func deploy(deployId string) error {
-	p(strategy, "deploying to %s\n", deployId)
-	err := beginDeployment(deployId)
+func deploy(ctx context.Context, deployId string) error {
+	defaultLogger.Info(strategy, fmt.Sprintf("deploying to %s", deployId), deployIdField(deployId))
+	err := beginDeployment(ctx, deployId)
 	if err != nil {
 		return err
 	}
-	err = deploySecrets(deployId)
+	err = deploySecrets(ctx, deployId)
 	if err != nil {
 		return err
 	}
-	err = deployFunction(deployId)
+	err = deployFunction(ctx, deployId)
 	if err != nil {
 		return err
 	}
-	err = deployStatics(deployId)
+	err = deployStatics(ctx, deployId)
 	if err != nil {
 		return err
 	}
-	err = activateDeployment(deployId)
+	err = activateDeployment(ctx, deployId)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func getDeployId() (string, error) {
-	resp, err := httpGet(fmt.Sprintf("%s/deploy", url))
+func getDeployId(ctx context.Context) (string, error) {
+	resp, err := httpGet(ctx, fmt.Sprintf("%s/deploy", url))
 	if err != nil {
 		return "", err
 	}
@@ -246,96 +481,170 @@ func getDeployId() (string, error) {
 	return string(body), nil
 }
 
-func deploySecrets(deployId string) error {
-	p("secrets", "starting to deploy secrets\n")
+func deploySecrets(ctx context.Context, deployId string) error {
+	defaultLogger.Info("secrets", "starting to deploy secrets", deployIdField(deployId))
 	for _, v := range envvars() {
-		p("secrets", "deploying %s", v.key)
-		resp, err := httpPut(fmt.Sprintf("%s/deploy/%s/secret/%s", url, deployId, v.key), "text/plain", strings.NewReader(v.value))
+		start := time.Now()
+		resp, err := httpPut(ctx, fmt.Sprintf("%s/deploy/%s/secret/%s", url, deployId, v.key), "text/plain", strings.NewReader(v.value))
+		elapsed := time.Since(start)
 		if err != nil {
 			return fmt.Errorf("error deploying secret (%s): %w", v.key, err)
 		}
 		if resp.StatusCode == http.StatusNoContent {
-			p("", " [OK]\n")
+			defaultLogger.Info("secrets", fmt.Sprintf("deployed %s", v.key), deployIdField(deployId), elapsedField(elapsed), statusCodeField(resp.StatusCode))
 		} else {
-			p("", " [%d]\n", resp.StatusCode)
+			defaultLogger.Error("secrets", fmt.Sprintf("failed to deploy %s", v.key), deployIdField(deployId), elapsedField(elapsed), statusCodeField(resp.StatusCode))
 			return fmt.Errorf("failed to deploy secret (%s)", v.key)
 		}
 	}
-	p("secrets", "successfully deployed\n")
+	defaultLogger.Info("secrets", "successfully deployed", deployIdField(deployId))
 	return nil
 }
 
-func bundle() ([]byte, error) {
-	entryFile := path.Join(funcDir, "index.js")
-	result := api.Build(api.BuildOptions{
-		Bundle:      true,
-		EntryPoints: []string{entryFile},
-		Platform:    api.PlatformNode,
-		LogLevel:    api.LogLevelInfo,
-	})
-	if len(result.Errors) > 0 {
-		for _, err := range result.Errors {
-			fmt.Println(err.Text)
+// deployStatics uploads only the static files whose content has changed
+// since the currently active deployment, and deletes files that have
+// since disappeared locally, by diffing local sha256 hashes against the
+// active deployment's manifest. --force-full bypasses the diff and
+// uploads everything.
+func deployStatics(ctx context.Context, deployId string) error {
+	if staticDir == "" {
+		return nil
+	}
+	defaultLogger.Info("statics", fmt.Sprintf("starting to deploy static files in '%s'", staticDir), deployIdField(deployId))
+	files, err := globAll(staticDir)
+	if err != nil {
+		return fmt.Errorf("error globbing files: %w", err)
+	}
+
+	pathToFile := make(map[string]string, len(files))
+	localHashes := make(map[string]string, len(files))
+	for _, f := range files {
+		fpath := filepath.ToSlash(removeDir(f, staticDir))
+		hash, err := sha256File(f)
+		if err != nil {
+			return fmt.Errorf("error hashing file (%s): %w", f, err)
 		}
-		return nil, errors.New("error while bundling")
+		pathToFile[fpath] = f
+		localHashes[fpath] = hash
 	}
-	return result.OutputFiles[0].Contents, nil
-}
 
-func deployFunction(deployId string) error {
-	p("functions", "starting to deploy functions in '%s'\n", funcDir)
-	p("functions", "creating bundle")
-	content, err := bundle()
-	if err != nil {
+	previousHashes := map[string]string{}
+	if !forceFull {
+		previousHashes, err = getStaticManifest(ctx)
+		if err != nil {
+			return fmt.Errorf("error fetching static manifest: %w", err)
+		}
+	}
+
+	var toUpload, toDelete []string
+	unchanged := 0
+	for fpath, hash := range localHashes {
+		if previousHashes[fpath] == hash {
+			unchanged++
+			continue
+		}
+		toUpload = append(toUpload, fpath)
+	}
+	for fpath := range previousHashes {
+		if _, ok := localHashes[fpath]; !ok {
+			toDelete = append(toDelete, fpath)
+		}
+	}
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(concurrency)
+	for _, fpath := range toUpload {
+		fpath := fpath
+		group.Go(func() error {
+			return uploadStaticFile(groupCtx, deployId, pathToFile[fpath], fpath)
+		})
+	}
+	for _, fpath := range toDelete {
+		fpath := fpath
+		group.Go(func() error {
+			return deleteStaticFile(groupCtx, deployId, fpath)
+		})
+	}
+	if err := group.Wait(); err != nil {
 		return err
 	}
-	p("", " [OK]\n")
 
-	p("functions", "deploying bundle")
-	resp, err := httpPut(fmt.Sprintf("%s/deploy/%s/function", url, deployId), "text/plain", bytes.NewReader(content))
+	defaultLogger.Info("statics", fmt.Sprintf("%d uploaded, %d unchanged, %d deleted", len(toUpload), unchanged, len(toDelete)), deployIdField(deployId))
+	return nil
+}
+
+func uploadStaticFile(ctx context.Context, deployId, f, fpath string) error {
+	contents, err := ioutil.ReadFile(f)
+	if err != nil {
+		return fmt.Errorf("error reading file (%s): %w", f, err)
+	}
+	contentType := http.DetectContentType(contents)
+	start := time.Now()
+	resp, err := httpPut(ctx, fmt.Sprintf("%s/deploy/%s/static/%s", url, deployId, fpath), contentType, bytes.NewReader(contents))
+	elapsed := time.Since(start)
 	if err != nil {
-		return fmt.Errorf("error deploying bundle: %w", err)
+		return fmt.Errorf("error deploying static file (%s): %w", f, err)
 	}
 	if resp.StatusCode == http.StatusNoContent {
-		p("", " [OK]\n")
-	} else {
-		p("", " [%d]\n", resp.StatusCode)
-		return fmt.Errorf("failed to deploy bundle")
+		defaultLogger.Info("statics", fmt.Sprintf("deployed %s", f), deployIdField(deployId), elapsedField(elapsed), statusCodeField(resp.StatusCode))
+		return nil
 	}
-	p("functions", "successfully deployed\n")
-	return nil
+	defaultLogger.Error("statics", fmt.Sprintf("failed to deploy %s", f), deployIdField(deployId), elapsedField(elapsed), statusCodeField(resp.StatusCode))
+	return fmt.Errorf("failed to deploy static file (%s)", f)
 }
 
-func deployStatics(deployId string) error {
-	if staticDir == "" {
+func deleteStaticFile(ctx context.Context, deployId, fpath string) error {
+	start := time.Now()
+	resp, err := httpDelete(ctx, fmt.Sprintf("%s/deploy/%s/static/%s", url, deployId, fpath))
+	elapsed := time.Since(start)
+	if err != nil {
+		return fmt.Errorf("error deleting static file (%s): %w", fpath, err)
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		defaultLogger.Info("statics", fmt.Sprintf("deleted %s", fpath), deployIdField(deployId), elapsedField(elapsed), statusCodeField(resp.StatusCode))
 		return nil
 	}
-	p("statics", "starting to deploy static files in '%s'\n", staticDir)
-	files, err := globAll(staticDir)
+	defaultLogger.Error("statics", fmt.Sprintf("failed to delete %s", fpath), deployIdField(deployId), elapsedField(elapsed), statusCodeField(resp.StatusCode))
+	return fmt.Errorf("failed to delete static file (%s)", fpath)
+}
+
+// getStaticManifest fetches the {path: sha256} manifest for the currently
+// active deployment's static files, so deployStatics can diff against it.
+// A missing manifest (e.g. first-ever deploy) is not an error.
+func getStaticManifest(ctx context.Context) (map[string]string, error) {
+	activeId, err := getDeployId(ctx)
 	if err != nil {
-		return fmt.Errorf("error globbing files: %w", err)
+		return nil, fmt.Errorf("error getting active deploy id: %w", err)
 	}
-	for _, f := range files {
-		p("statics", "deploying file %s", f)
-		contents, err := ioutil.ReadFile(f)
-		if err != nil {
-			return fmt.Errorf("error reading file (%s): %w", f, err)
-		}
-		fpath := filepath.ToSlash(removeDir(f, staticDir))
-		contentType := http.DetectContentType(contents)
-		resp, err := httpPut(fmt.Sprintf("%s/deploy/%s/static/%s", url, deployId, fpath), contentType, bytes.NewReader(contents))
-		if err != nil {
-			return fmt.Errorf("error deploying static file (%s): %w", f, err)
-		}
-		if resp.StatusCode == http.StatusNoContent {
-			p("", " [OK]\n")
-		} else {
-			p("", " [%d]\n", resp.StatusCode)
-			return fmt.Errorf("failed to deploy static file (%s)", f)
-		}
+	resp, err := httpGet(ctx, fmt.Sprintf("%s/deploy/%s/static/manifest", url, activeId))
+	if err != nil {
+		return nil, err
 	}
-	p("statics", "successfully deployed\n")
-	return nil
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotFound {
+		return map[string]string{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch static manifest: %d", resp.StatusCode)
+	}
+	var manifest map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("error decoding static manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+func sha256File(f string) (string, error) {
+	file, err := os.Open(f)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
 func removeDir(f, dir string) string {
@@ -346,34 +655,83 @@ func removeDir(f, dir string) string {
 	return s
 }
 
-func beginDeployment(deployId string) error {
-	p("begin", "starting deployment %s\n", deployId)
-	resp, err := httpPost(fmt.Sprintf("%s/deploy/%s/begin", url, deployId), "text/plain", nil)
+func beginDeployment(ctx context.Context, deployId string) error {
+	defaultLogger.Info("begin", fmt.Sprintf("starting deployment %s", deployId), deployIdField(deployId))
+	start := time.Now()
+	resp, err := httpPost(ctx, fmt.Sprintf("%s/deploy/%s/begin", url, deployId), "text/plain", nil)
+	elapsed := time.Since(start)
 	if err != nil {
 		return fmt.Errorf("error starting deployment: %w", err)
 	}
 	if resp.StatusCode == http.StatusNoContent {
-		p("begin", "successfully started deployment %s\n", deployId)
+		defaultLogger.Info("begin", fmt.Sprintf("successfully started deployment %s", deployId), deployIdField(deployId), elapsedField(elapsed), statusCodeField(resp.StatusCode))
 	} else {
-		return fmt.Errorf("failed to begin deployment: %w", err)
+		defaultLogger.Error("begin", "failed to begin deployment", deployIdField(deployId), elapsedField(elapsed), statusCodeField(resp.StatusCode))
+		return fmt.Errorf("failed to begin deployment: status %d", resp.StatusCode)
 	}
 	return nil
 }
 
-func activateDeployment(deployId string) error {
-	p("activate", "starting to activate %s\n", deployId)
-	resp, err := httpPost(fmt.Sprintf("%s/deploy/%s/activate", url, deployId), "text/plain", nil)
+func activateDeployment(ctx context.Context, deployId string) error {
+	defaultLogger.Info("activate", fmt.Sprintf("starting to activate %s", deployId), deployIdField(deployId))
+	start := time.Now()
+	resp, err := httpPost(ctx, fmt.Sprintf("%s/deploy/%s/activate", url, deployId), "text/plain", nil)
+	elapsed := time.Since(start)
 	if err != nil {
 		return fmt.Errorf("error activating deployment: %w", err)
 	}
 	if resp.StatusCode == http.StatusNoContent {
-		p("activate", "successfully activated %s\n", deployId)
+		defaultLogger.Info("activate", fmt.Sprintf("successfully activated %s", deployId), deployIdField(deployId), elapsedField(elapsed), statusCodeField(resp.StatusCode))
 	} else {
-		return fmt.Errorf("failed to activate deployment: %w", err)
+		defaultLogger.Error("activate", "failed to activate deployment", deployIdField(deployId), elapsedField(elapsed), statusCodeField(resp.StatusCode))
+		return fmt.Errorf("failed to activate deployment: status %d", resp.StatusCode)
 	}
 	return nil
 }
 
+// deploymentRecord describes a single deployment as reported by
+// GET /deploy/history.
+type deploymentRecord struct {
+	Id        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Active    bool   `json:"active"`
+	GitRev    string `json:"git_rev,omitempty"`
+}
+
+func getDeployHistory(ctx context.Context) ([]deploymentRecord, error) {
+	resp, err := httpGet(ctx, fmt.Sprintf("%s/deploy/history", url))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching deployment history: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch deployment history: %d", resp.StatusCode)
+	}
+	var records []deploymentRecord
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+		return nil, fmt.Errorf("error decoding deployment history: %w", err)
+	}
+	return records, nil
+}
+
+func deployHistoryContains(records []deploymentRecord, deployId string) bool {
+	for _, r := range records {
+		if r.Id == deployId {
+			return true
+		}
+	}
+	return false
+}
+
+func printDeployHistory(records []deploymentRecord) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tTIMESTAMP\tACTIVE\tGIT REV")
+	for _, r := range records {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%t\t%s\n", r.Id, r.Timestamp, r.Active, r.GitRev)
+	}
+	_ = w.Flush()
+}
+
 func globAll(dir string) ([]string, error) {
 	files := make([]string, 0)
 	err := filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
@@ -394,13 +752,23 @@ type keyval struct {
 	value string
 }
 
+// envvars returns the secrets to deploy: every ATROCITY_-prefixed
+// environment variable, plus any profile "secrets" entries not already
+// set via the environment.
 func envvars() []keyval {
 	result := make([]keyval, 0)
+	seen := make(map[string]bool)
 	for _, e := range os.Environ() {
 		pair := strings.SplitN(e, "=", 2)
 		if strings.HasPrefix(pair[0], "ATROCITY_") {
 			key := strings.Replace(pair[0], "ATROCITY_", "", 1)
 			result = append(result, keyval{key, pair[1]})
+			seen[key] = true
+		}
+	}
+	for key, value := range profileSecrets {
+		if !seen[key] {
+			result = append(result, keyval{key, value})
 		}
 	}
 	return result
@@ -421,9 +789,17 @@ func init() {
 	deployCmd.Flags().StringVarP(&staticDir, "staticDir", "s", "", fmt.Sprintf("the directory that contains static assets to deploy [%s]", atroctlStaticDir))
 	deployCmd.Flags().StringVarP(&strategy, "strategy", "g", "", fmt.Sprintf("the deployment strategy (bluegreen, gitrev, uuid) [%s]", atroctlStrategy))
 	deployCmd.Flags().BoolVarP(&watch, "watch", "w", false, "deploy when directory changes")
+	deployCmd.Flags().DurationVar(&watchDebounce, "watch-debounce", defaultWatchDebounce, "debounce window for coalescing file system events while watching")
+	deployCmd.Flags().BoolVar(&forceFull, "force-full", false, "upload every static file instead of only those that changed")
+	deployCmd.Flags().IntVar(&concurrency, "concurrency", defaultConcurrency, "number of static files to upload or delete concurrently")
+	deployCmd.Flags().StringVar(&bundlePlatform, "bundle-platform", "node", "esbuild platform to bundle functions for (node, browser, neutral)")
+	deployCmd.Flags().StringVar(&bundleTarget, "bundle-target", "", "esbuild language target (es2015..es2022, esnext); defaults to esbuild's default")
+	deployCmd.Flags().BoolVar(&bundleMinify, "bundle-minify", false, "minify the bundle")
+	deployCmd.Flags().StringVar(&bundleSourcemap, "bundle-sourcemap", "none", "source map mode (none, inline, external); external PUTs the .map alongside the bundle")
+	deployCmd.Flags().StringArrayVar(&bundleEntries, "bundle-entry", nil, "entry point to bundle, relative to the current directory (repeatable); defaults to funcDir/index.js")
+	deployCmd.Flags().StringArrayVar(&bundleExternal, "bundle-external", nil, "package to exclude from the bundle (repeatable)")
+	deployCmd.Flags().StringArrayVar(&bundleDefine, "bundle-define", nil, "KEY=VALUE compile-time constant to define (repeatable)")
 	rootCmd.AddCommand(deployCmd)
-
-	funcDir = resolveStringFlag(funcDir, atroctlFuncDir, "src")
-	staticDir = resolveStringFlag(staticDir, atroctlStaticDir, "")
-	strategy = resolveStringFlag(strategy, atroctlStaticDir, "bluegreen")
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(rollbackCmd)
 }
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestBluegreenStrategyNextDeployId(t *testing.T) {
+	cases := []struct {
+		current string
+		want    string
+		wantErr bool
+	}{
+		{current: "blue", want: "green"},
+		{current: "green", want: "blue"},
+		{current: "unknown", wantErr: true},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.current, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(c.current))
+			}))
+			defer server.Close()
+
+			origURL := url
+			url = server.URL
+			defer func() { url = origURL }()
+
+			got, err := (bluegreenStrategy{}).NextDeployId(context.Background())
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got deploy id %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != c.want {
+				t.Fatalf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestUuidStrategyNextDeployId(t *testing.T) {
+	a, err := (uuidStrategy{}).NextDeployId(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b, err := (uuidStrategy{}).NextDeployId(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if a == b {
+		t.Fatalf("expected two different uuids, got %q twice", a)
+	}
+}
+
+func TestGetDeployHistory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/deploy/history" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`[{"id":"blue","timestamp":"2026-07-01T00:00:00Z","active":true,"git_rev":"abc1234"}]`))
+	}))
+	defer server.Close()
+
+	origURL := url
+	url = server.URL
+	defer func() { url = origURL }()
+
+	records, err := getDeployHistory(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(records) != 1 || records[0].Id != "blue" || !records[0].Active {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+	if !deployHistoryContains(records, "blue") {
+		t.Fatalf("expected history to contain %q", "blue")
+	}
+	if deployHistoryContains(records, "green") {
+		t.Fatalf("expected history not to contain %q", "green")
+	}
+}
+
+// writeFakeGit installs a fake "git" executable on PATH for the duration of
+// the test that echoes fixedRev for "rev-parse" and fails for everything
+// else, so the gitrev strategy test doesn't depend on an actual git
+// installation or repository state.
+func writeFakeGit(t *testing.T, fixedRev string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake git shim is written for POSIX shells")
+	}
+
+	dir := t.TempDir()
+	script := fmt.Sprintf("#!/bin/sh\ncase \"$1\" in\n  rev-parse) echo %s ;;\n  *) exit 1 ;;\nesac\n", fixedRev)
+	gitPath := filepath.Join(dir, "git")
+	if err := os.WriteFile(gitPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("writing fake git: %s", err)
+	}
+
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestGitrevStrategyNextDeployId(t *testing.T) {
+	writeFakeGit(t, "abc1234")
+
+	origFuncDir := funcDir
+	funcDir = t.TempDir()
+	defer func() { funcDir = origFuncDir }()
+
+	got, err := (gitrevStrategy{}).NextDeployId(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != "abc1234" {
+		t.Fatalf("got %q, want %q", got, "abc1234")
+	}
+}
+
+func TestGitrevStrategyFallsBackToDescribe(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\ncase \"$1\" in\n  rev-parse) exit 1 ;;\n  describe) echo v1.2.3-dirty ;;\n  *) exit 1 ;;\nesac\n"
+	if runtime.GOOS == "windows" {
+		t.Skip("fake git shim is written for POSIX shells")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "git"), []byte(script), 0o755); err != nil {
+		t.Fatalf("writing fake git: %s", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	origFuncDir := funcDir
+	funcDir = t.TempDir()
+	defer func() { funcDir = origFuncDir }()
+
+	got, err := (gitrevStrategy{}).NextDeployId(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != "v1.2.3-dirty" {
+		t.Fatalf("got %q, want %q", got, "v1.2.3-dirty")
+	}
+}
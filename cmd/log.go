@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+type logLevel int
+
+const (
+	logLevelDebug logLevel = iota
+	logLevelInfo
+	logLevelWarn
+	logLevelError
+)
+
+func parseLogLevel(s string) (logLevel, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return logLevelDebug, nil
+	case "info":
+		return logLevelInfo, nil
+	case "warn":
+		return logLevelWarn, nil
+	case "error":
+		return logLevelError, nil
+	default:
+		return logLevelInfo, fmt.Errorf("log level (%s) not supported", s)
+	}
+}
+
+var (
+	logFormat    string
+	logLevelFlag string
+)
+
+const (
+	atroctlLogFormat = "ATROCTL_LOG_FORMAT"
+	atroctlLogLevel  = "ATROCTL_LOG_LEVEL"
+)
+
+// event is a single structured log event. In --log-format=json it's
+// emitted as one JSON object per event; otherwise it's rendered as a
+// human-readable line.
+type event struct {
+	Component  string `json:"component"`
+	Msg        string `json:"msg"`
+	DeployId   string `json:"deploy_id,omitempty"`
+	ElapsedMs  int64  `json:"elapsed_ms,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// field attaches an optional structured value to a log event.
+type field func(*event)
+
+func deployIdField(id string) field {
+	return func(e *event) { e.DeployId = id }
+}
+
+func elapsedField(d time.Duration) field {
+	return func(e *event) { e.ElapsedMs = d.Milliseconds() }
+}
+
+func statusCodeField(code int) field {
+	return func(e *event) { e.StatusCode = code }
+}
+
+func errField(err error) field {
+	return func(e *event) {
+		if err != nil {
+			e.Error = err.Error()
+		}
+	}
+}
+
+// logger emits events as either human-readable lines (matching atroctl's
+// original output) or one JSON object per event, filtered by level.
+type logger struct {
+	level  logLevel
+	format string
+	out    io.Writer
+}
+
+var defaultLogger = &logger{level: logLevelInfo, format: "text", out: os.Stdout}
+
+func (l *logger) log(level logLevel, component, msg string, fields ...field) {
+	if level < l.level {
+		return
+	}
+	e := event{Component: component, Msg: msg}
+	for _, f := range fields {
+		f(&e)
+	}
+	if l.format == "json" {
+		_ = json.NewEncoder(l.out).Encode(e)
+		return
+	}
+	line := msg
+	if e.StatusCode != 0 {
+		line = fmt.Sprintf("%s [%d]", line, e.StatusCode)
+	}
+	if e.Error != "" {
+		line = fmt.Sprintf("%s: %s", line, e.Error)
+	}
+	if component == "" {
+		_, _ = fmt.Fprintf(l.out, "%s\n", line)
+		return
+	}
+	_, _ = fmt.Fprintf(l.out, "%10s: %s\n", strings.ToUpper(component), line)
+}
+
+func (l *logger) Debug(component, msg string, fields ...field) {
+	l.log(logLevelDebug, component, msg, fields...)
+}
+func (l *logger) Info(component, msg string, fields ...field) {
+	l.log(logLevelInfo, component, msg, fields...)
+}
+func (l *logger) Warn(component, msg string, fields ...field) {
+	l.log(logLevelWarn, component, msg, fields...)
+}
+func (l *logger) Error(component, msg string, fields ...field) {
+	l.log(logLevelError, component, msg, fields...)
+}
+
+func init() {
+	deployCmd.PersistentFlags().StringVar(&logFormat, "log-format", "", fmt.Sprintf("log output format (text, json) [%s]", atroctlLogFormat))
+	deployCmd.PersistentFlags().StringVar(&logLevelFlag, "log-level", "", fmt.Sprintf("minimum log level (debug, info, warn, error) [%s]", atroctlLogLevel))
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoggerTextFormat(t *testing.T) {
+	var buf bytes.Buffer
+	l := &logger{level: logLevelInfo, format: "text", out: &buf}
+
+	l.Info("secrets", "deployed FOO", statusCodeField(204))
+	l.Debug("secrets", "should be filtered out")
+
+	out := buf.String()
+	if !strings.Contains(out, "SECRETS: deployed FOO [204]") {
+		t.Fatalf("unexpected text output: %q", out)
+	}
+	if strings.Contains(out, "filtered out") {
+		t.Fatalf("debug event should have been filtered at info level: %q", out)
+	}
+}
+
+func TestLoggerJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	l := &logger{level: logLevelInfo, format: "json", out: &buf}
+
+	l.Error("functions", "failed to deploy bundle",
+		deployIdField("blue"),
+		elapsedField(250*time.Millisecond),
+		statusCodeField(500),
+		errField(errors.New("boom")))
+
+	var e event
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatalf("expected valid json, got error %s for %q", err, buf.String())
+	}
+	if e.Component != "functions" || e.Msg != "failed to deploy bundle" || e.DeployId != "blue" || e.ElapsedMs != 250 || e.StatusCode != 500 || e.Error != "boom" {
+		t.Fatalf("unexpected event: %+v", e)
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	cases := map[string]logLevel{
+		"debug": logLevelDebug,
+		"INFO":  logLevelInfo,
+		"warn":  logLevelWarn,
+		"error": logLevelError,
+	}
+	for input, want := range cases {
+		got, err := parseLogLevel(input)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %q: %s", input, err)
+		}
+		if got != want {
+			t.Fatalf("parseLogLevel(%q) = %v, want %v", input, got, want)
+		}
+	}
+
+	if _, err := parseLogLevel("verbose"); err == nil {
+		t.Fatal("expected an error for an unsupported log level")
+	}
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSha256File(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatalf("writing file: %s", err)
+	}
+
+	got, err := sha256File(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestGetStaticManifest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/deploy":
+			_, _ = w.Write([]byte("blue"))
+		case "/deploy/blue/static/manifest":
+			_, _ = w.Write([]byte(`{"index.html":"abc123"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	origURL := url
+	url = server.URL
+	defer func() { url = origURL }()
+
+	manifest, err := getStaticManifest(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if manifest["index.html"] != "abc123" {
+		t.Fatalf("unexpected manifest: %+v", manifest)
+	}
+}
+
+func TestGetStaticManifestMissing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/deploy":
+			_, _ = w.Write([]byte("blue"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	origURL := url
+	url = server.URL
+	defer func() { url = origURL }()
+
+	manifest, err := getStaticManifest(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(manifest) != 0 {
+		t.Fatalf("expected an empty manifest, got %+v", manifest)
+	}
+}
+
+func TestDeployStaticsUploadsChangedAndDeletesRemoved(t *testing.T) {
+	origStaticDir, origForceFull, origConcurrency, origURL := staticDir, forceFull, concurrency, url
+	defer func() {
+		staticDir, forceFull, concurrency, url = origStaticDir, origForceFull, origConcurrency, origURL
+	}()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "unchanged.txt"), []byte("same"), 0o644); err != nil {
+		t.Fatalf("writing file: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "changed.txt"), []byte("new content"), 0o644); err != nil {
+		t.Fatalf("writing file: %s", err)
+	}
+	staticDir = dir
+	forceFull = false
+	concurrency = defaultConcurrency
+
+	unchangedHash, err := sha256File(filepath.Join(dir, "unchanged.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var mu sync.Mutex
+	var uploaded, deleted []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/deploy":
+			_, _ = w.Write([]byte("blue"))
+		case r.URL.Path == "/deploy/blue/static/manifest":
+			_, _ = w.Write([]byte(`{"unchanged.txt":"` + unchangedHash + `","changed.txt":"old-hash","removed.txt":"old-hash"}`))
+		case r.Method == http.MethodPut:
+			mu.Lock()
+			uploaded = append(uploaded, r.URL.Path)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodDelete:
+			mu.Lock()
+			deleted = append(deleted, r.URL.Path)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	url = server.URL
+
+	if err := deployStatics(context.Background(), "green"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(uploaded) != 1 || uploaded[0] != "/deploy/green/static/changed.txt" {
+		t.Fatalf("expected only changed.txt to be uploaded, got %v", uploaded)
+	}
+	if len(deleted) != 1 || deleted[0] != "/deploy/green/static/removed.txt" {
+		t.Fatalf("expected only removed.txt to be deleted, got %v", deleted)
+	}
+}
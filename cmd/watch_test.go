@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShouldIgnore(t *testing.T) {
+	root := "/home/user/.config/myproj/src"
+	patterns := []string{"*.log", "build/*"}
+	cases := map[string]bool{
+		filepath.Join(root, "index.js"):           false,
+		filepath.Join(root, ".git/HEAD"):          true,
+		filepath.Join(root, "node_modules/foo"):   true,
+		filepath.Join(root, "src/node_modules/x"): true,
+		filepath.Join(root, "debug.log"):          true,
+		filepath.Join(root, "build/out.js"):       true,
+		root:                                      false,
+	}
+	for p, want := range cases {
+		if got := shouldIgnore(root, p, patterns); got != want {
+			t.Errorf("shouldIgnore(%q, %q) = %v, want %v", root, p, got, want)
+		}
+	}
+}
+
+func TestLoadIgnorePatterns(t *testing.T) {
+	dir := t.TempDir()
+	content := "# comment\n*.log\n\nbuild/*\n"
+	if err := os.WriteFile(filepath.Join(dir, atroctlIgnoreFile), []byte(content), 0o644); err != nil {
+		t.Fatalf("writing .atroctlignore: %s", err)
+	}
+
+	patterns, err := loadIgnorePatterns(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []string{"*.log", "build/*"}
+	if len(patterns) != len(want) {
+		t.Fatalf("got %v, want %v", patterns, want)
+	}
+	for i := range want {
+		if patterns[i] != want[i] {
+			t.Fatalf("got %v, want %v", patterns, want)
+		}
+	}
+}
+
+func TestLoadIgnorePatternsMissingFile(t *testing.T) {
+	patterns, err := loadIgnorePatterns(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if patterns != nil {
+		t.Fatalf("expected no patterns, got %v", patterns)
+	}
+}
+
+// TestStartWatchingDebouncesRapidWrites simulates an editor saving a file
+// several times in quick succession and asserts the writes coalesce into a
+// single deploy rather than one per file system event.
+func TestStartWatchingDebouncesRapidWrites(t *testing.T) {
+	origFuncDir, origStaticDir, origDebounce := funcDir, staticDir, watchDebounce
+	defer func() {
+		funcDir, staticDir, watchDebounce = origFuncDir, origStaticDir, origDebounce
+	}()
+
+	funcDir = t.TempDir()
+	staticDir = ""
+	watchDebounce = 50 * time.Millisecond
+
+	var deployCount int32
+	deployed := make(chan struct{}, 1)
+	fn := deployFunc(func(ctx context.Context) error {
+		if atomic.AddInt32(&deployCount, 1) == 1 {
+			deployed <- struct{}{}
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- startWatching(ctx, fn) }()
+
+	// Give the watcher time to add the directory before writing to it.
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		path := filepath.Join(funcDir, "index.js")
+		if err := os.WriteFile(path, []byte("//"+time.Now().String()), 0o644); err != nil {
+			t.Fatalf("writing file: %s", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case <-deployed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a debounced deploy to run")
+	}
+
+	// Let the debounce window fully settle, then confirm no extra deploys
+	// were triggered by the earlier burst of writes.
+	time.Sleep(200 * time.Millisecond)
+	if got := atomic.LoadInt32(&deployCount); got != 1 {
+		t.Fatalf("expected exactly 1 coalesced deploy, got %d", got)
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil && err != context.Canceled {
+			t.Fatalf("unexpected error from startWatching: %s", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("startWatching did not return after cancellation")
+	}
+}